@@ -0,0 +1,80 @@
+package v3
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// AuthConfig holds the fields shared by every auth provider's config object.
+type AuthConfig struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Type    string `json:"type"`
+	Enabled bool   `json:"enabled,omitempty"`
+}
+
+// OIDCConfig is the persisted configuration for the generic OIDC auth provider.
+type OIDCConfig struct {
+	AuthConfig `json:",inline" mapstructure:",squash"`
+
+	ClientID            string   `json:"clientId"`
+	ClientSecret        string   `json:"clientSecret" norman:"type=password"`
+	Scopes              string   `json:"scope"`
+	RancherURL          string   `json:"rancherUrl"`
+	Certificate         string   `json:"certificate"`
+	PrivateKey          string   `json:"privateKey" norman:"type=password"`
+	Issuer              string   `json:"issuer"`
+	AccessMode          string   `json:"accessMode" norman:"type=string,options=required|restricted|unrestricted,default=unrestricted"`
+	AllowedPrincipalIDs []string `json:"allowedPrincipalIds"`
+
+	// ClaimMappings lets admins point Rancher at the claims their IdP actually
+	// emits instead of assuming the OIDC-standard names.
+	ClaimMappings *OIDCClaimMappings `json:"claimMappings,omitempty"`
+
+	// AuthBackend selects which PrincipalSearcher implementation backs
+	// SearchPrincipals/GetPrincipal, e.g. "keycloak", "azuread", "okta".
+	// Leaving it empty keeps the legacy synthetic-principal behavior.
+	AuthBackend string `json:"authBackend,omitempty"`
+	// ServiceAccountClientID/Secret are client-credentials used to search
+	// and look up principals on AuthBackend's API, so admins can authorize
+	// users and groups before they've ever logged in. These are
+	// intentionally separate from ClientID/ClientSecret, which are only
+	// ever used on behalf of an already-authenticated end user.
+	ServiceAccountClientID     string `json:"serviceAccountClientId,omitempty"`
+	ServiceAccountClientSecret string `json:"serviceAccountClientSecret,omitempty" norman:"type=password"`
+}
+
+// OIDCClaimMappings maps Rancher principal attributes onto the claims an IdP
+// returns in the ID token / UserInfo response. Claim names are dotted paths
+// so nested/vendor claims (e.g. "resource_access.rancher.roles") can be
+// reached without Rancher having to know about every IdP's claim layout.
+type OIDCClaimMappings struct {
+	// UIDClaim selects the claim used as the principal's stable subject
+	// identifier. Defaults to "sub" when empty.
+	UIDClaim string `json:"uidClaim,omitempty"`
+	// UsernameClaim selects the claim used for the login name. Defaults to
+	// "email" when empty.
+	UsernameClaim string `json:"usernameClaim,omitempty"`
+	// DisplayNameClaim selects the claim used for the display name. Defaults
+	// to "name" when empty.
+	DisplayNameClaim string `json:"displayNameClaim,omitempty"`
+	// GroupsClaim selects the claim holding group membership. Defaults to
+	// "groups" when empty.
+	GroupsClaim string `json:"groupsClaim,omitempty"`
+	// GroupsPrefix is stripped from (or, if not present, allows filtering
+	// of) group values before they're turned into principals, e.g. IdPs
+	// that return "group:engineering" instead of "engineering".
+	GroupsPrefix string `json:"groupsPrefix,omitempty"`
+	// GroupsFilter is an optional regular expression; only group values
+	// matching it are turned into group principals. Useful when a claim
+	// like "realm_access.roles" mixes Rancher groups with unrelated roles.
+	GroupsFilter string `json:"groupsFilter,omitempty"`
+}
+
+// OIDCLogin carries the authorization code handed back by the IdP's redirect.
+type OIDCLogin struct {
+	Code        string `json:"code"`
+	State       string `json:"state"`
+	Description string `json:"description,omitempty"`
+	TTLMillis   int64  `json:"responseTTLInMillis,omitempty"`
+}
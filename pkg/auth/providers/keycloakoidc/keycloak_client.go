@@ -1,18 +1,32 @@
 package keycloakoidc
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
 
 	v32 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
 	"github.com/rancher/rancher/pkg/auth/providers/oidc"
+	v3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
 	"github.com/sirupsen/logrus"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+func init() {
+	oidc.RegisterPrincipalSearcher("keycloak", func(config *v32.OIDCConfig) (oidc.PrincipalSearcher, error) {
+		return &KClient{config: config}, nil
+	})
+}
+
 //account defines properties an account in keycloak has
 type account struct {
 	ID            int    `json:"id,omitempty"`
@@ -34,18 +48,46 @@ type Group struct {
 
 //KClient implements a httpclient for keycloak
 type KClient struct {
-	httpClient *http.Client
+	httpClient         *http.Client
+	httpClientOnce     sync.Once
+	httpClientErr      error
+	config             *v32.OIDCConfig
+	serviceTokenSource oauth2.TokenSource
 }
 
-func (k *KClient) newClient(config *v32.OIDCConfig) (KClient, error) {
-	kClient := KClient{}
-	if config.Certificate != "" && config.PrivateKey != "" {
-		err := oidc.GetClientWithCertKey(kClient.httpClient, config.Certificate, config.PrivateKey)
+// client returns the cached *http.Client for config, building it (with the
+// optional mTLS transport) on first use.
+func (k *KClient) client(config *v32.OIDCConfig) (*http.Client, error) {
+	k.httpClientOnce.Do(func() {
+		k.httpClient, k.httpClientErr = newHTTPClient(config)
+	})
+	return k.httpClient, k.httpClientErr
+}
+
+// newHTTPClient builds the *http.Client used to talk to the Keycloak admin
+// API. When a client cert/key pair is configured it's used for mTLS;
+// otherwise the default transport is used, augmented with config.Certificate
+// as a trusted CA when set, so Rancher can reach Keycloak instances behind a
+// private CA without disabling TLS verification.
+func newHTTPClient(config *v32.OIDCConfig) (*http.Client, error) {
+	if config.Certificate == "" {
+		return &http.Client{}, nil
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if config.PrivateKey != "" {
+		cert, err := tls.X509KeyPair([]byte(config.Certificate), []byte(config.PrivateKey))
 		if err != nil {
-			return KClient{}, err
+			return nil, fmt.Errorf("[keycloak oidc]: failed to load client cert/key: %w", err)
 		}
+		transport.TLSClientConfig = &tls.Config{Certificates: []tls.Certificate{cert}}
+		return &http.Client{Transport: transport}, nil
 	}
-	return kClient, nil
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM([]byte(config.Certificate)) {
+		return nil, fmt.Errorf("[keycloak oidc]: failed to parse configured CA certificate")
+	}
+	transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	return &http.Client{Transport: transport}, nil
 }
 
 func (k *KClient) searchPrincipals(searchTerm, principalType string, accessToken string, config *v32.OIDCConfig) ([]account, error) {
@@ -158,8 +200,97 @@ func URLEncoded(str string) string {
 	return u.String()
 }
 
+// SearchUsers implements oidc.PrincipalSearcher.
+func (k *KClient) SearchUsers(searchTerm string) ([]v3.Principal, error) {
+	return k.searchAsPrincipals(searchTerm, UserType)
+}
+
+// SearchGroups implements oidc.PrincipalSearcher.
+func (k *KClient) SearchGroups(searchTerm string) ([]v3.Principal, error) {
+	return k.searchAsPrincipals(searchTerm, GroupType)
+}
+
+// GetByID implements oidc.PrincipalSearcher.
+func (k *KClient) GetByID(principalType, id string) (v3.Principal, error) {
+	token, err := k.serviceAccountToken()
+	if err != nil {
+		return v3.Principal{}, err
+	}
+	a, err := k.getFromKeyCloakByID(id, token, principalType+"s", k.config)
+	if err != nil {
+		return v3.Principal{}, err
+	}
+	a.Type = principalType
+	return accountToPrincipal(a), nil
+}
+
+func (k *KClient) searchAsPrincipals(searchTerm, principalType string) ([]v3.Principal, error) {
+	token, err := k.serviceAccountToken()
+	if err != nil {
+		return nil, err
+	}
+	accounts, err := k.searchPrincipals(searchTerm, principalType, token, k.config)
+	if err != nil {
+		return nil, err
+	}
+	principals := make([]v3.Principal, 0, len(accounts))
+	for _, a := range accounts {
+		principals = append(principals, accountToPrincipal(a))
+	}
+	return principals, nil
+}
+
+// serviceAccountToken obtains (and caches) a client-credentials token for
+// config.ServiceAccountClientID/Secret, used to search and look up
+// principals Rancher admins want to authorize before those users have ever
+// logged in themselves.
+func (k *KClient) serviceAccountToken() (string, error) {
+	if k.serviceTokenSource == nil {
+		tokenURL, err := getTokenURL(k.config.Issuer)
+		if err != nil {
+			return "", err
+		}
+		k.serviceTokenSource = (&clientcredentials.Config{
+			ClientID:     k.config.ServiceAccountClientID,
+			ClientSecret: k.config.ServiceAccountClientSecret,
+			TokenURL:     tokenURL,
+		}).TokenSource(context.Background())
+	}
+	token, err := k.serviceTokenSource.Token()
+	if err != nil {
+		return "", fmt.Errorf("[keycloak oidc]: failed to obtain service account token: %w", err)
+	}
+	return token.AccessToken, nil
+}
+
+func getTokenURL(issuer string) (string, error) {
+	sURL, err := getSearchURL(issuer)
+	if err != nil {
+		return "", err
+	}
+	return strings.Replace(sURL, "/admin/", "/", 1) + "/protocol/openid-connect/token", nil
+}
+
+func accountToPrincipal(a account) v3.Principal {
+	idValue := a.Username
+	displayName := a.Username
+	if a.Type == GroupType {
+		idValue = a.Name
+		displayName = a.Name
+	} else if a.Name != "" {
+		displayName = a.Name
+	}
+	return v3.Principal{
+		ObjectMeta:    metav1.ObjectMeta{Name: oidc.Name + "_" + a.Type + "://" + idValue},
+		DisplayName:   displayName,
+		LoginName:     a.Username,
+		PrincipalType: a.Type,
+		Provider:      oidc.Name,
+	}
+}
+
 func (k *KClient) getFromKeyCloak(accessToken, url string, config *v32.OIDCConfig) ([]byte, int, error) {
-	kHTTPClient, err := k.newClient(config)
+	httpClient, err := k.client(config)
 	if err != nil {
 		logrus.Errorf("[keycloak oidc]: error creating new http client: %v", err)
 		return nil, 500, err
@@ -168,13 +299,14 @@ func (k *KClient) getFromKeyCloak(accessToken, url string, config *v32.OIDCConfi
 	if err != nil {
 		return nil, 500, err
 	}
-	req.Header.Add("Authorization", "token "+accessToken)
+	req.Header.Add("Authorization", "Bearer "+accessToken)
 	req.Header.Add("Accept", "application/json")
-	resp, err := kHTTPClient.httpClient.Do(req)
+	resp, err := httpClient.Do(req)
 	if err != nil {
 		logrus.Errorf("[keycloak oidc]: received error from keycloak: %v", err)
-		return nil, resp.StatusCode, err
+		return nil, 500, err
 	}
+	defer resp.Body.Close()
 	b, err := ioutil.ReadAll(resp.Body)
 	switch resp.StatusCode {
 	case 200:
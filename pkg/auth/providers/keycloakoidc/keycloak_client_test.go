@@ -0,0 +1,98 @@
+package keycloakoidc
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	v32 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestServer stands up a Keycloak stub serving the admin search endpoints
+// KClient hits, plus the client-credentials token endpoint used to obtain
+// the service account token. handler is wired to respond to
+// /auth/admin/realms/test/{users,groups}.
+func newTestServer(t *testing.T, handler http.HandlerFunc) (*httptest.Server, *v32.OIDCConfig) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/auth/realms/test/protocol/openid-connect/token", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"access_token":"service-token","token_type":"Bearer","expires_in":300}`)
+	})
+	mux.HandleFunc("/auth/admin/realms/test/users", handler)
+	mux.HandleFunc("/auth/admin/realms/test/groups", handler)
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	config := &v32.OIDCConfig{Issuer: srv.URL + "/auth/realms/test"}
+	return srv, config
+}
+
+func TestSearchUsers(t *testing.T) {
+	_, config := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer service-token", r.Header.Get("Authorization"))
+		if r.URL.Path != "/auth/admin/realms/test/users" {
+			http.NotFound(w, r)
+			return
+		}
+		assert.Equal(t, "alice", r.URL.Query().Get("search"))
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode([]account{
+			{Username: "alice", Name: "Alice Example"},
+		})
+	})
+	k := &KClient{config: config}
+
+	principals, err := k.SearchUsers("alice")
+
+	require.NoError(t, err)
+	require.Len(t, principals, 1)
+	assert.Equal(t, "alice", principals[0].LoginName)
+	assert.Equal(t, UserType, principals[0].PrincipalType)
+}
+
+func TestSearchGroupsIncludesNestedSubgroups(t *testing.T) {
+	_, config := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/auth/admin/realms/test/groups" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode([]Group{
+			{
+				ID:   1,
+				Name: "engineering",
+				Subgroups: []Group{
+					{ID: 2, Name: "platform", Subgroups: []Group{
+						{ID: 3, Name: "sre"},
+					}},
+				},
+			},
+		})
+	})
+	k := &KClient{config: config}
+
+	principals, err := k.SearchGroups("eng")
+
+	require.NoError(t, err)
+	require.Len(t, principals, 3)
+	var names []string
+	for _, p := range principals {
+		names = append(names, p.DisplayName)
+	}
+	assert.ElementsMatch(t, []string{"engineering", "platform", "sre"}, names)
+}
+
+func TestSearchUsersUnauthorized(t *testing.T) {
+	_, config := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	})
+	k := &KClient{config: config}
+
+	principals, err := k.SearchUsers("alice")
+
+	require.NoError(t, err)
+	assert.Empty(t, principals)
+}
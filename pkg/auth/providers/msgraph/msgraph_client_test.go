@@ -0,0 +1,13 @@
+package msgraph
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTokenURLStripsV2Suffix(t *testing.T) {
+	got := tokenURL("https://login.microsoftonline.com/contoso-tenant-id/v2.0")
+
+	assert.Equal(t, "https://login.microsoftonline.com/contoso-tenant-id/oauth2/v2.0/token", got)
+}
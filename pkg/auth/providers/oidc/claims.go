@@ -0,0 +1,183 @@
+package oidc
+
+import (
+	"context"
+	"regexp"
+	"strings"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	v32 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"golang.org/x/oauth2"
+)
+
+const (
+	defaultUIDClaim         = "sub"
+	defaultUsernameClaim    = "email"
+	defaultDisplayNameClaim = "name"
+	defaultGroupsClaim      = "groups"
+)
+
+// claimMapper resolves Rancher principal attributes out of a raw claims map
+// according to an OIDCConfig's ClaimMappings, falling back to the OIDC
+// standard claim names when no mapping is configured.
+type claimMapper struct {
+	uidClaim         string
+	usernameClaim    string
+	displayNameClaim string
+	groupsClaim      string
+	groupsPrefix     string
+	groupsFilter     *regexp.Regexp
+}
+
+func newClaimMapper(config *v32.OIDCConfig) (*claimMapper, error) {
+	m := &claimMapper{
+		uidClaim:         defaultUIDClaim,
+		usernameClaim:    defaultUsernameClaim,
+		displayNameClaim: defaultDisplayNameClaim,
+		groupsClaim:      defaultGroupsClaim,
+	}
+	mappings := config.ClaimMappings
+	if mappings == nil {
+		return m, nil
+	}
+	if mappings.UIDClaim != "" {
+		m.uidClaim = mappings.UIDClaim
+	}
+	if mappings.UsernameClaim != "" {
+		m.usernameClaim = mappings.UsernameClaim
+	}
+	if mappings.DisplayNameClaim != "" {
+		m.displayNameClaim = mappings.DisplayNameClaim
+	}
+	if mappings.GroupsClaim != "" {
+		m.groupsClaim = mappings.GroupsClaim
+	}
+	m.groupsPrefix = mappings.GroupsPrefix
+	if mappings.GroupsFilter != "" {
+		re, err := regexp.Compile(mappings.GroupsFilter)
+		if err != nil {
+			return nil, err
+		}
+		m.groupsFilter = re
+	}
+	return m, nil
+}
+
+func (m *claimMapper) uid(claims map[string]interface{}) string {
+	return claimString(claims, m.uidClaim)
+}
+
+func (m *claimMapper) username(claims map[string]interface{}) string {
+	return claimString(claims, m.usernameClaim)
+}
+
+func (m *claimMapper) displayName(claims map[string]interface{}) string {
+	return claimString(claims, m.displayNameClaim)
+}
+
+// groups returns the group names found at groupsClaim, normalized, prefix
+// stripped and filtered according to the mapper's configuration.
+func (m *claimMapper) groups(claims map[string]interface{}) []string {
+	var groups []string
+	for _, g := range claimStringSlice(claims, m.groupsClaim) {
+		if m.groupsPrefix != "" {
+			if !strings.HasPrefix(g, m.groupsPrefix) {
+				continue
+			}
+			g = strings.TrimPrefix(g, m.groupsPrefix)
+		}
+		if m.groupsFilter != nil && !m.groupsFilter.MatchString(g) {
+			continue
+		}
+		if g != "" {
+			groups = append(groups, g)
+		}
+	}
+	return groups
+}
+
+// claimString resolves a JSONPath-like dotted path (e.g.
+// "resource_access.rancher.roles") against a raw claims map and returns it
+// as a string. Non-string leaf values are ignored.
+func claimString(claims map[string]interface{}, path string) string {
+	value := resolveClaimPath(claims, path)
+	s, _ := value.(string)
+	return s
+}
+
+// claimStringSlice resolves a dotted path and normalizes the result to a
+// []string, accepting either a JSON array of strings or a single string.
+func claimStringSlice(claims map[string]interface{}, path string) []string {
+	value := resolveClaimPath(claims, path)
+	switch v := value.(type) {
+	case []string:
+		return v
+	case []interface{}:
+		var out []string
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	case string:
+		if v == "" {
+			return nil
+		}
+		return []string{v}
+	default:
+		return nil
+	}
+}
+
+// mergeUserInfoClaims augments claims (already populated from the verified
+// ID token) with whatever the discovery document's UserInfo endpoint
+// returns, when one is advertised. ID token claims win on conflict: some
+// IdPs (notably Azure AD) omit "groups" from UserInfo, and we'd rather keep
+// what the ID token already told us than silently drop group membership.
+func mergeUserInfoClaims(ctx context.Context, provider *oidc.Provider, token *oauth2.Token, claims rawClaims) error {
+	var discovery struct {
+		UserInfoEndpoint string `json:"userinfo_endpoint"`
+	}
+	if err := provider.Claims(&discovery); err != nil {
+		return err
+	}
+	if discovery.UserInfoEndpoint == "" {
+		return nil
+	}
+
+	userInfo, err := provider.UserInfo(ctx, oauth2.StaticTokenSource(token))
+	if err != nil {
+		return err
+	}
+	var userInfoClaims rawClaims
+	if err := userInfo.Claims(&userInfoClaims); err != nil {
+		return err
+	}
+	for k, v := range userInfoClaims {
+		if _, exists := claims[k]; !exists {
+			claims[k] = v
+		}
+	}
+	return nil
+}
+
+// resolveClaimPath walks a dotted path through nested maps, e.g. for
+// "resource_access.rancher.roles" it descends claims["resource_access"]["rancher"]["roles"].
+func resolveClaimPath(claims map[string]interface{}, path string) interface{} {
+	if path == "" {
+		return nil
+	}
+	var current interface{} = claims
+	for _, segment := range strings.Split(path, ".") {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		current, ok = m[segment]
+		if !ok {
+			return nil
+		}
+	}
+	return current
+}
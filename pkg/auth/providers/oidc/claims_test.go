@@ -0,0 +1,113 @@
+package oidc
+
+import (
+	"testing"
+
+	v32 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveClaimPathNested(t *testing.T) {
+	claims := map[string]interface{}{
+		"resource_access": map[string]interface{}{
+			"rancher": map[string]interface{}{
+				"roles": []interface{}{"admin", "view"},
+			},
+		},
+		"sub": "user-123",
+	}
+
+	tests := []struct {
+		name string
+		path string
+		want interface{}
+	}{
+		{"top level", "sub", "user-123"},
+		{"nested", "resource_access.rancher.roles", []interface{}{"admin", "view"}},
+		{"missing top level", "nope", nil},
+		{"missing nested segment", "resource_access.rancher.nope", nil},
+		{"descends into non-map leaf", "sub.nope", nil},
+		{"empty path", "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, resolveClaimPath(claims, tt.path))
+		})
+	}
+}
+
+func TestClaimStringSliceNormalizesValueTypes(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+		want []string
+	}{
+		{"interface slice of strings", "ifaceGroups", []string{"engineering", "platform"}},
+		{"native string slice", "nativeGroups", []string{"engineering", "platform"}},
+		{"single string", "singleGroup", []string{"engineering"}},
+		{"empty string", "emptyGroup", nil},
+		{"missing claim", "missing", nil},
+		{"interface slice with non-string elements skipped", "mixedGroups", []string{"engineering"}},
+	}
+	claims := map[string]interface{}{
+		"ifaceGroups":  []interface{}{"engineering", "platform"},
+		"nativeGroups": []string{"engineering", "platform"},
+		"singleGroup":  "engineering",
+		"emptyGroup":   "",
+		"mixedGroups":  []interface{}{"engineering", 42, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, claimStringSlice(claims, tt.path))
+		})
+	}
+}
+
+func TestClaimMapperGroupsAppliesPrefixAndFilter(t *testing.T) {
+	mapper, err := newClaimMapper(&v32.OIDCConfig{
+		ClaimMappings: &v32.OIDCClaimMappings{
+			GroupsClaim:  "resource_access.rancher.roles",
+			GroupsPrefix: "group:",
+			GroupsFilter: "^eng.*",
+		},
+	})
+	require.NoError(t, err)
+
+	claims := map[string]interface{}{
+		"resource_access": map[string]interface{}{
+			"rancher": map[string]interface{}{
+				"roles": []interface{}{"group:engineering", "group:sales", "norprefix", "group:eng-platform"},
+			},
+		},
+	}
+
+	got := mapper.groups(claims)
+
+	assert.Equal(t, []string{"engineering", "eng-platform"}, got)
+}
+
+func TestClaimMapperUsesDefaultsWhenUnconfigured(t *testing.T) {
+	mapper, err := newClaimMapper(&v32.OIDCConfig{})
+	require.NoError(t, err)
+
+	claims := map[string]interface{}{
+		"sub":    "user-123",
+		"email":  "alice@example.com",
+		"name":   "Alice Example",
+		"groups": []interface{}{"engineering"},
+	}
+
+	assert.Equal(t, "user-123", mapper.uid(claims))
+	assert.Equal(t, "alice@example.com", mapper.username(claims))
+	assert.Equal(t, "Alice Example", mapper.displayName(claims))
+	assert.Equal(t, []string{"engineering"}, mapper.groups(claims))
+}
+
+func TestNewClaimMapperRejectsInvalidGroupsFilter(t *testing.T) {
+	_, err := newClaimMapper(&v32.OIDCConfig{
+		ClaimMappings: &v32.OIDCClaimMappings{GroupsFilter: "("},
+	})
+
+	assert.Error(t, err)
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"sync"
 
 	"github.com/coreos/go-oidc/v3/oidc"
 	"github.com/mitchellh/mapstructure"
@@ -38,19 +39,18 @@ type OpenIDCProvider struct {
 	Secrets     corev1.SecretInterface
 	UserMGR     user.Manager
 	TokenMGR    *tokens.Manager
-}
 
-type claimInfo struct {
-	Subject           string   `json:"sub"`
-	Name              string   `json:"name"`
-	PreferredUsername string   `json:preferred_username`
-	GivenName         string   `json:given_name`
-	FamilyName        string   `json:family_name`
-	Email             string   `json:"email"`
-	EmailVerified     bool     `json:"email_verified"`
-	Groups            []string `json:"groups"`
+	searcherMu  sync.Mutex
+	searcherKey string
+	searcher    PrincipalSearcher
 }
 
+// rawClaims is the generic shape claims come back in before claimMapper
+// resolves Rancher's fixed principal attributes out of it. Kept as
+// map[string]interface{} rather than a struct since the dotted paths in
+// ClaimMappings can point anywhere in the claim tree.
+type rawClaims map[string]interface{}
+
 func Configure(ctx context.Context, mgmtCtx *config.ScaledContext, userMGR user.Manager, tokenMGR *tokens.Manager) common.AuthProvider {
 	return &OpenIDCProvider{
 		CTX:         ctx,
@@ -85,7 +85,6 @@ func (o *OpenIDCProvider) AuthenticateUser(ctx context.Context, input interface{
 func (o *OpenIDCProvider) LoginUser(ctx context.Context, oauthLoginInfo *v32.OIDCLogin, config *v32.OIDCConfig) (v3.Principal, []v3.Principal, string, error) {
 	var userPrincipal v3.Principal
 	var groupPrincipals []v3.Principal
-	var claimInfo claimInfo
 	var err error
 
 	if config == nil {
@@ -122,61 +121,98 @@ func (o *OpenIDCProvider) LoginUser(ctx context.Context, oauthLoginInfo *v32.OID
 
 	rawToken, ok := oauth2Token.Extra("id_token").(string)
 	if !ok {
-		rawToken, ok = oauth2Token.Extra("access_token").(string)
-		if !ok {
-			return userPrincipal, groupPrincipals, "", err
-		}
+		return userPrincipal, groupPrincipals, "", fmt.Errorf("[generic oidc]: id_token missing from token response")
 	}
 	var verifier = provider.Verifier(&oidc.Config{ClientID: config.ClientID})
 	// parse and verify the id token payload
-	_, err = verifier.Verify(ctx, rawToken)
+	idToken, err := verifier.Verify(ctx, rawToken)
 	if err != nil {
 		return userPrincipal, groupPrincipals, "", err
 	}
-	userInfo, err := provider.UserInfo(ctx, oauth2.StaticTokenSource(oauth2Token))
-	if err != nil {
+	var claims rawClaims
+	if err := idToken.Claims(&claims); err != nil {
+		return userPrincipal, groupPrincipals, "", err
+	}
+	if err := mergeUserInfoClaims(ctx, provider, oauth2Token, claims); err != nil {
 		return userPrincipal, groupPrincipals, "", err
 	}
-	if err := userInfo.Claims(&claimInfo); err != nil {
+
+	mapper, err := newClaimMapper(config)
+	if err != nil {
 		return userPrincipal, groupPrincipals, "", err
 	}
 
-	userPrincipal = o.userToPrincipal(userInfo, claimInfo)
+	userPrincipal = o.userToPrincipal(claims, mapper)
 	userPrincipal.Me = true
 
-	for _, group := range claimInfo.Groups {
+	if err := o.persistRefreshToken(userPrincipal.Name, oauth2Token); err != nil {
+		logrus.Errorf("[generic oidc] loginuser: failed to persist refresh token: %v", err)
+	}
+
+	for _, group := range mapper.groups(claims) {
 		groupPrincipal := o.groupToPrincipal(group)
 		groupPrincipal.MemberOf = true
 		groupPrincipals = append(groupPrincipals, groupPrincipal)
 
 	}
 	logrus.Debugf("[generic oidc] loginuser: Checking user's access to Rancher")
-	allowed, err := o.UserMGR.CheckAccess(config.AccessMode, config.AllowedPrincipalIDs, userPrincipal.Name, groupPrincipals)
+	allowed, err := o.CanAccessWithGroupProviders(userPrincipal.Name, groupPrincipals)
 	if err != nil {
 		return userPrincipal, groupPrincipals, "", err
 	}
 	if !allowed {
 		return userPrincipal, groupPrincipals, "", httperror.NewAPIError(httperror.Unauthorized, "unauthorized")
 	}
+
+	grantedScopes := o.mintDownstreamScopes(userPrincipal, groupPrincipals, derivedScopes(groupPrincipals, config.AllowedPrincipalIDs))
+	if err := o.persistScopes(userPrincipal.Name, grantedScopes); err != nil {
+		logrus.Errorf("[generic oidc] loginuser: failed to persist derived token scopes: %v", err)
+	}
+
 	return userPrincipal, groupPrincipals, oauth2Token.AccessToken, nil
 }
 
 func (o *OpenIDCProvider) SearchPrincipals(searchValue, principalType string, token v3.Token) ([]v3.Principal, error) {
-	var principals []v3.Principal
-
 	if principalType == "" {
 		principalType = UserType
 	}
 
-	p := v3.Principal{
-		ObjectMeta:    metav1.ObjectMeta{Name: o.GetName() + "_" + principalType + "://" + searchValue},
-		DisplayName:   searchValue,
-		LoginName:     searchValue,
-		PrincipalType: principalType,
-		Provider:      o.GetName(),
+	config, err := o.GetOIDCConfig()
+	if err != nil {
+		return nil, err
+	}
+	searcher, err := o.principalSearcher(config)
+	if err != nil {
+		return nil, err
+	}
+	if searcher == nil {
+		// No AuthBackend configured: fall back to the legacy behavior of
+		// echoing the search term back as a single synthetic principal.
+		p := v3.Principal{
+			ObjectMeta:    metav1.ObjectMeta{Name: o.GetName() + "_" + principalType + "://" + searchValue},
+			DisplayName:   searchValue,
+			LoginName:     searchValue,
+			PrincipalType: principalType,
+			Provider:      o.GetName(),
+		}
+		return []v3.Principal{p}, nil
 	}
 
-	principals = append(principals, p)
+	var principals []v3.Principal
+	if principalType == "" || principalType == UserType {
+		users, err := searcher.SearchUsers(searchValue)
+		if err != nil {
+			return nil, err
+		}
+		principals = append(principals, users...)
+	}
+	if principalType == "" || principalType == GroupType {
+		groups, err := searcher.SearchGroups(searchValue)
+		if err != nil {
+			return nil, err
+		}
+		principals = append(principals, groups...)
+	}
 	return principals, nil
 }
 
@@ -202,7 +238,21 @@ func (o *OpenIDCProvider) GetPrincipal(principalID string, token v3.Token) (v3.P
 	if principalType != UserType && principalType != GroupType {
 		return p, fmt.Errorf("[generic oidc]: invalid principal type")
 	}
-	if principalID == UserType {
+	config, err := o.GetOIDCConfig()
+	if err != nil {
+		return p, err
+	}
+	searcher, err := o.principalSearcher(config)
+	if err != nil {
+		return p, err
+	}
+
+	if searcher != nil {
+		p, err = searcher.GetByID(principalType, externalID)
+		if err != nil {
+			return p, err
+		}
+	} else if principalID == UserType {
 		p = v3.Principal{
 			ObjectMeta:    metav1.ObjectMeta{Name: principalType + "://" + externalID},
 			DisplayName:   externalID,
@@ -233,9 +283,75 @@ func (o *OpenIDCProvider) getRedirectURL(config map[string]interface{}) string {
 }
 
 func (o *OpenIDCProvider) RefetchGroupPrincipals(principalID string, secret string) ([]v3.Principal, error) {
-	return nil, errors.New("[generic oidc]: not implemented")
+	config, err := o.GetOIDCConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := o.CTX
+	if err := o.AddCertKeyToContext(&ctx, config.Certificate, config.PrivateKey); err != nil {
+		return nil, err
+	}
+
+	tokenSecret, err := o.loadTokenSecret(principalID, secret)
+	if err != nil {
+		return nil, err
+	}
+	if tokenSecret.RefreshToken == "" {
+		return nil, errors.New("[generic oidc]: no refresh token on file for this user, they must log in again")
+	}
+
+	provider, err := oidc.NewProvider(ctx, config.Issuer)
+	if err != nil {
+		return nil, err
+	}
+	oauthConfig := oauth2.Config{
+		ClientID:     config.ClientID,
+		ClientSecret: config.ClientSecret,
+		Endpoint:     provider.Endpoint(),
+		Scopes:       append([]string{oidc.ScopeOpenID}, strings.Split(config.Scopes, ",")...),
+	}
+
+	refreshedToken, err := oauthConfig.TokenSource(ctx, &oauth2.Token{RefreshToken: tokenSecret.RefreshToken}).Token()
+	if err != nil {
+		return nil, errors.Wrap(err, "[generic oidc]: failed to refresh token")
+	}
+
+	rawIDToken, ok := refreshedToken.Extra("id_token").(string)
+	if !ok {
+		return nil, errors.New("[generic oidc]: id_token missing from refresh response")
+	}
+	idToken, err := provider.Verifier(&oidc.Config{ClientID: config.ClientID}).Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, err
+	}
+	var claims rawClaims
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, err
+	}
+	if err := mergeUserInfoClaims(ctx, provider, refreshedToken, claims); err != nil {
+		return nil, err
+	}
+
+	if err := o.persistRefreshToken(principalID, refreshedToken); err != nil {
+		logrus.Errorf("[generic oidc] refetchgroupprincipals: failed to persist refreshed token: %v", err)
+	}
+
+	mapper, err := newClaimMapper(config)
+	if err != nil {
+		return nil, err
+	}
+
+	var groupPrincipals []v3.Principal
+	for _, group := range mapper.groups(claims) {
+		groupPrincipal := o.groupToPrincipal(group)
+		groupPrincipal.MemberOf = true
+		groupPrincipals = append(groupPrincipals, groupPrincipal)
+	}
+	return groupPrincipals, nil
 }
 
+// CanAccessWithGroupProviders implements common.AuthProvider.
 func (o *OpenIDCProvider) CanAccessWithGroupProviders(userPrincipalID string, groupPrincipals []v3.Principal) (bool, error) {
 	config, err := o.GetOIDCConfig()
 	if err != nil {
@@ -249,15 +365,17 @@ func (o *OpenIDCProvider) CanAccessWithGroupProviders(userPrincipalID string, gr
 	return allowed, nil
 }
 
-func (o *OpenIDCProvider) userToPrincipal(userInfo *oidc.UserInfo, info claimInfo) v3.Principal {
-	displayName := info.Name
+func (o *OpenIDCProvider) userToPrincipal(claims rawClaims, mapper *claimMapper) v3.Principal {
+	uid := mapper.uid(claims)
+	displayName := mapper.displayName(claims)
+	loginName := mapper.username(claims)
 	if displayName == "" {
-		displayName = userInfo.Email
+		displayName = loginName
 	}
 	p := v3.Principal{
-		ObjectMeta:    metav1.ObjectMeta{Name: o.GetName() + "_" + UserType + "://" + userInfo.Subject},
+		ObjectMeta:    metav1.ObjectMeta{Name: o.GetName() + "_" + UserType + "://" + uid},
 		DisplayName:   displayName,
-		LoginName:     userInfo.Email,
+		LoginName:     loginName,
 		Provider:      o.GetName(),
 		PrincipalType: UserType,
 		Me:            false,
@@ -318,6 +436,11 @@ func (o *OpenIDCProvider) saveOIDCConfig(config *v32.OIDCConfig) error {
 		return err
 	}
 
+	saField := strings.ToLower(client.OIDCConfigFieldServiceAccountClientSecret)
+	if err := common.CreateOrUpdateSecrets(o.Secrets, convert.ToString(config.ServiceAccountClientSecret), saField, strings.ToLower(config.Type)); err != nil {
+		return err
+	}
+
 	logrus.Debugf("[generic oidc] updating config")
 	_, err = o.AuthConfigs.ObjectClient().Update(config.ObjectMeta.Name, config)
 	return err
@@ -365,6 +488,16 @@ func (o *OpenIDCProvider) GetOIDCConfig() (*v32.OIDCConfig, error) {
 		}
 	}
 
+	if storedOidcConfig.ServiceAccountClientSecret != "" {
+		data, err := common.ReadFromSecretData(o.Secrets, storedOidcConfig.ServiceAccountClientSecret)
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range data {
+			storedOidcConfig.ServiceAccountClientSecret = string(v)
+		}
+	}
+
 	return storedOidcConfig, nil
 }
 
@@ -0,0 +1,80 @@
+package oidc
+
+import (
+	"fmt"
+	"sync"
+
+	v32 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	v3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
+)
+
+// PrincipalSearcher is implemented once per IdP flavor (Keycloak, Azure AD,
+// Okta, ...) so SearchPrincipals/GetPrincipal can look up real users and
+// groups instead of echoing back the search term.
+type PrincipalSearcher interface {
+	SearchUsers(searchTerm string) ([]v3.Principal, error)
+	SearchGroups(searchTerm string) ([]v3.Principal, error)
+	GetByID(principalType, id string) (v3.Principal, error)
+}
+
+// PrincipalSearcherFactory builds a PrincipalSearcher for a given OIDCConfig.
+// Implementations register themselves under their AuthBackend name from an
+// init() func, so this package never has to import flavor-specific packages
+// (and flavor packages are free to import this one for shared helpers).
+type PrincipalSearcherFactory func(config *v32.OIDCConfig) (PrincipalSearcher, error)
+
+var (
+	searcherFactoriesMu sync.RWMutex
+	searcherFactories   = map[string]PrincipalSearcherFactory{}
+)
+
+// RegisterPrincipalSearcher makes a PrincipalSearcherFactory available under
+// backend, for use when OIDCConfig.AuthBackend == backend. It is meant to be
+// called from a flavor package's init() func.
+func RegisterPrincipalSearcher(backend string, factory PrincipalSearcherFactory) {
+	searcherFactoriesMu.Lock()
+	defer searcherFactoriesMu.Unlock()
+	searcherFactories[backend] = factory
+}
+
+// newPrincipalSearcher looks up the factory registered for config.AuthBackend
+// and builds a PrincipalSearcher from it. Returns (nil, nil) when AuthBackend
+// is unset, so callers can fall back to the legacy synthetic-principal
+// behavior instead of treating it as an error.
+func newPrincipalSearcher(config *v32.OIDCConfig) (PrincipalSearcher, error) {
+	if config.AuthBackend == "" {
+		return nil, nil
+	}
+	searcherFactoriesMu.RLock()
+	factory, ok := searcherFactories[config.AuthBackend]
+	searcherFactoriesMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("[generic oidc]: no principal searcher registered for authBackend %q", config.AuthBackend)
+	}
+	return factory(config)
+}
+
+// principalSearcher returns the PrincipalSearcher for config, reusing the
+// previously built one as long as config.AuthBackend and ResourceVersion
+// haven't changed. Without this, SearchPrincipals/GetPrincipal would
+// construct (and tear down) a fresh backend client - with its own
+// sync.Once-cached HTTP client and service-account token - on every single
+// search/lookup call.
+func (o *OpenIDCProvider) principalSearcher(config *v32.OIDCConfig) (PrincipalSearcher, error) {
+	key := config.AuthBackend + "@" + config.ResourceVersion
+
+	o.searcherMu.Lock()
+	defer o.searcherMu.Unlock()
+
+	if o.searcher != nil && o.searcherKey == key {
+		return o.searcher, nil
+	}
+
+	searcher, err := newPrincipalSearcher(config)
+	if err != nil {
+		return nil, err
+	}
+	o.searcher = searcher
+	o.searcherKey = key
+	return searcher, nil
+}
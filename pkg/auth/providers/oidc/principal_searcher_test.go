@@ -0,0 +1,57 @@
+package oidc
+
+import (
+	"testing"
+
+	v32 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	v3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type fakeSearcher struct{ instance int }
+
+func (f *fakeSearcher) SearchUsers(string) ([]v3.Principal, error)   { return nil, nil }
+func (f *fakeSearcher) SearchGroups(string) ([]v3.Principal, error)  { return nil, nil }
+func (f *fakeSearcher) GetByID(string, string) (v3.Principal, error) { return v3.Principal{}, nil }
+
+func TestPrincipalSearcherReusesInstanceForUnchangedConfig(t *testing.T) {
+	built := 0
+	RegisterPrincipalSearcher("fake-cache-reuse", func(config *v32.OIDCConfig) (PrincipalSearcher, error) {
+		built++
+		return &fakeSearcher{instance: built}, nil
+	})
+
+	o := &OpenIDCProvider{}
+	config := &v32.OIDCConfig{AuthConfig: v32.AuthConfig{ObjectMeta: metav1.ObjectMeta{ResourceVersion: "1"}}, AuthBackend: "fake-cache-reuse"}
+
+	first, err := o.principalSearcher(config)
+	require.NoError(t, err)
+	second, err := o.principalSearcher(config)
+	require.NoError(t, err)
+
+	assert.Same(t, first, second)
+	assert.Equal(t, 1, built)
+}
+
+func TestPrincipalSearcherRebuildsWhenResourceVersionChanges(t *testing.T) {
+	built := 0
+	RegisterPrincipalSearcher("fake-cache-invalidate", func(config *v32.OIDCConfig) (PrincipalSearcher, error) {
+		built++
+		return &fakeSearcher{instance: built}, nil
+	})
+
+	o := &OpenIDCProvider{}
+	config := &v32.OIDCConfig{AuthConfig: v32.AuthConfig{ObjectMeta: metav1.ObjectMeta{ResourceVersion: "1"}}, AuthBackend: "fake-cache-invalidate"}
+
+	first, err := o.principalSearcher(config)
+	require.NoError(t, err)
+
+	config.ResourceVersion = "2"
+	second, err := o.principalSearcher(config)
+	require.NoError(t, err)
+
+	assert.NotSame(t, first, second)
+	assert.Equal(t, 2, built)
+}
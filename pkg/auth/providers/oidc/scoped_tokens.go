@@ -0,0 +1,88 @@
+package oidc
+
+import (
+	"strings"
+	"time"
+
+	"github.com/rancher/rancher/pkg/auth/tokens/scope"
+	v3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
+	"github.com/sirupsen/logrus"
+)
+
+// defaultScopedTokenTTL is the Expiry derivedScopes gives every scope, so a
+// token minted from one of those scopes stays valid for at most this long,
+// independent of the parent Rancher token's own lifetime.
+const defaultScopedTokenTTL = time.Hour
+
+// derivedScopes returns one TokenScope per group principal that's also
+// listed in config.AllowedPrincipalIDs, so the token handed to downstream
+// components (e.g. a generated kubeconfig) only ever carries the union of
+// resources the user's own group memberships already entitle them to. The
+// group's external ID (the part after "oidc_group://") is used verbatim as
+// the scope's Resource, e.g. a group named "cluster:c-xxxxx" only grants
+// access to that cluster.
+func derivedScopes(groupPrincipals []v3.Principal, allowedPrincipalIDs []string) []scope.TokenScope {
+	allowed := make(map[string]bool, len(allowedPrincipalIDs))
+	for _, id := range allowedPrincipalIDs {
+		allowed[id] = true
+	}
+	var scopes []scope.TokenScope
+	for _, g := range groupPrincipals {
+		if !allowed[g.Name] {
+			continue
+		}
+		_, resource, ok := strings.Cut(g.Name, "://")
+		if !ok || resource == "" {
+			continue
+		}
+		scopes = append(scopes, scope.TokenScope{
+			Resource: resource,
+			Role:     "view",
+			Expiry:   defaultScopedTokenTTL,
+		})
+	}
+	return scopes
+}
+
+// ScopesForPrincipal returns the TokenScopes LoginUser most recently derived
+// for principalID, so callers outside the login flow (e.g. kubeconfig
+// generation) can mint a downstream token without the user present.
+func (o *OpenIDCProvider) ScopesForPrincipal(principalID string) ([]scope.TokenScope, error) {
+	secret, err := o.loadTokenSecret(principalID, "")
+	if err != nil {
+		return nil, err
+	}
+	return secret.Scopes, nil
+}
+
+// MintDownstreamToken derives a narrowly-scoped child token from parent, so
+// a downstream component (e.g. a cluster kubeconfig) can be handed
+// something with a much smaller blast radius than the user's raw Rancher
+// token. parent must carry at least one group principal, which the "group"
+// Verifier checks before the token is minted; it does not check which
+// group the scope's Resource came from.
+func (o *OpenIDCProvider) MintDownstreamToken(parent *v3.Token, tokenScope scope.TokenScope) (*v3.Token, error) {
+	return scope.Mint(parent, "group", tokenScope)
+}
+
+// mintDownstreamScopes mints a child token via MintDownstreamToken for each
+// candidate scope, so groupVerifier actually runs at login time instead of
+// candidates only ever being written straight to the persisted secret.
+// Scopes MintDownstreamToken refuses (e.g. because the synthesized parent
+// carries no group principals) are dropped rather than failing the whole
+// login.
+func (o *OpenIDCProvider) mintDownstreamScopes(userPrincipal v3.Principal, groupPrincipals []v3.Principal, candidates []scope.TokenScope) []scope.TokenScope {
+	if len(candidates) == 0 {
+		return nil
+	}
+	parent := &v3.Token{UserPrincipal: userPrincipal, GroupPrincipals: groupPrincipals}
+	granted := make([]scope.TokenScope, 0, len(candidates))
+	for _, s := range candidates {
+		if _, err := o.MintDownstreamToken(parent, s); err != nil {
+			logrus.Warnf("[generic oidc] loginuser: not granting scope %s/%s to %s: %v", s.Resource, s.Role, userPrincipal.Name, err)
+			continue
+		}
+		granted = append(granted, s)
+	}
+	return granted
+}
@@ -0,0 +1,54 @@
+package oidc
+
+import (
+	"testing"
+
+	"github.com/rancher/rancher/pkg/auth/tokens/scope"
+	v3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestDerivedScopesOnlyIncludesAllowedGroups(t *testing.T) {
+	groups := []v3.Principal{
+		{ObjectMeta: metav1.ObjectMeta{Name: "oidc_group://cluster:c-xxxxx"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "oidc_group://not-allowed"}},
+	}
+
+	scopes := derivedScopes(groups, []string{"oidc_group://cluster:c-xxxxx"})
+
+	assert.Len(t, scopes, 1)
+	assert.Equal(t, "cluster:c-xxxxx", scopes[0].Resource)
+	assert.Equal(t, "view", scopes[0].Role)
+}
+
+func TestDerivedScopesEmptyWhenNoGroupsAllowed(t *testing.T) {
+	groups := []v3.Principal{
+		{ObjectMeta: metav1.ObjectMeta{Name: "oidc_group://cluster:c-xxxxx"}},
+	}
+
+	scopes := derivedScopes(groups, nil)
+
+	assert.Empty(t, scopes)
+}
+
+func TestMintDownstreamScopesGrantsScopesTheGroupVerifierAllows(t *testing.T) {
+	o := &OpenIDCProvider{}
+	userPrincipal := v3.Principal{ObjectMeta: metav1.ObjectMeta{Name: "oidc_user://alice"}}
+	groupPrincipals := []v3.Principal{{ObjectMeta: metav1.ObjectMeta{Name: "oidc_group://cluster:c-xxxxx"}}}
+	candidates := []scope.TokenScope{{Resource: "cluster:c-xxxxx", Role: "view", Expiry: defaultScopedTokenTTL}}
+
+	granted := o.mintDownstreamScopes(userPrincipal, groupPrincipals, candidates)
+
+	assert.Equal(t, candidates, granted)
+}
+
+func TestMintDownstreamScopesDropsScopesTheGroupVerifierRejects(t *testing.T) {
+	o := &OpenIDCProvider{}
+	userPrincipal := v3.Principal{ObjectMeta: metav1.ObjectMeta{Name: "oidc_user://alice"}}
+	candidates := []scope.TokenScope{{Resource: "cluster:c-xxxxx", Role: "view", Expiry: defaultScopedTokenTTL}}
+
+	granted := o.mintDownstreamScopes(userPrincipal, nil, candidates)
+
+	assert.Empty(t, granted)
+}
@@ -0,0 +1,74 @@
+package oidc
+
+import (
+	"encoding/json"
+
+	"github.com/rancher/rancher/pkg/auth/tokens/scope"
+	"golang.org/x/oauth2"
+)
+
+// oidcTokenSecret is what we persist, via TokenMGR, keyed by principal ID.
+// It lets RefetchGroupPrincipals obtain a fresh id_token/access_token later
+// without asking the user to log in again, and lets downstream components
+// look up the TokenScopes LoginUser derived for this principal without
+// recomputing them from the IdP's groups claim.
+type oidcTokenSecret struct {
+	RefreshToken string             `json:"refreshToken"`
+	AccessToken  string             `json:"accessToken"`
+	Scopes       []scope.TokenScope `json:"scopes,omitempty"`
+}
+
+// persistRefreshToken stores the refresh token (and current access token)
+// for principalID so a later RefetchGroupPrincipals call can exchange it
+// for a new id_token, keeping any TokenScopes already stored alongside. It
+// is a no-op when the IdP didn't return a refresh token, e.g. when the
+// "offline_access" scope wasn't granted.
+func (o *OpenIDCProvider) persistRefreshToken(principalID string, token *oauth2.Token) error {
+	if token.RefreshToken == "" {
+		return nil
+	}
+	current, err := o.loadTokenSecret(principalID, "")
+	if err != nil {
+		current = oidcTokenSecret{}
+	}
+	current.RefreshToken = token.RefreshToken
+	current.AccessToken = token.AccessToken
+	secret, err := json.Marshal(current)
+	if err != nil {
+		return err
+	}
+	return o.TokenMGR.UpdateSecret(principalID, Name, string(secret))
+}
+
+// persistScopes saves the TokenScopes LoginUser derived for principalID,
+// preserving whatever persistRefreshToken already stored alongside them.
+func (o *OpenIDCProvider) persistScopes(principalID string, scopes []scope.TokenScope) error {
+	current, err := o.loadTokenSecret(principalID, "")
+	if err != nil {
+		return err
+	}
+	current.Scopes = scopes
+	secret, err := json.Marshal(current)
+	if err != nil {
+		return err
+	}
+	return o.TokenMGR.UpdateSecret(principalID, Name, string(secret))
+}
+
+// loadTokenSecret reads back what persistRefreshToken stored for
+// principalID, falling back to the secret passed in explicitly (e.g. by
+// RefetchGroupPrincipals, which is handed the Rancher token's own secret).
+func (o *OpenIDCProvider) loadTokenSecret(principalID, fallback string) (oidcTokenSecret, error) {
+	var secret oidcTokenSecret
+	raw, err := o.TokenMGR.GetSecret(principalID, Name, nil)
+	if err != nil || raw == "" {
+		raw = fallback
+	}
+	if raw == "" {
+		return secret, nil
+	}
+	if err := json.Unmarshal([]byte(raw), &secret); err != nil {
+		return secret, err
+	}
+	return secret, nil
+}
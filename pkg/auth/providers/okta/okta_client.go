@@ -0,0 +1,185 @@
+package okta
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	v32 "github.com/rancher/rancher/pkg/apis/management.cattle.io/v3"
+	"github.com/rancher/rancher/pkg/auth/providers/oidc"
+	v3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func init() {
+	oidc.RegisterPrincipalSearcher("okta", func(config *v32.OIDCConfig) (oidc.PrincipalSearcher, error) {
+		return &Client{config: config}, nil
+	})
+}
+
+// user and group are the subset of Okta's response fields Rancher needs to
+// build a principal.
+type user struct {
+	ID      string `json:"id"`
+	Profile struct {
+		Login     string `json:"login"`
+		Email     string `json:"email"`
+		FirstName string `json:"firstName"`
+		LastName  string `json:"lastName"`
+	} `json:"profile"`
+}
+
+type group struct {
+	ID      string `json:"id"`
+	Profile struct {
+		Name string `json:"name"`
+	} `json:"profile"`
+}
+
+// Client implements oidc.PrincipalSearcher against the Okta API, for
+// OIDCConfig.AuthBackend == "okta".
+type Client struct {
+	config             *v32.OIDCConfig
+	serviceTokenSource oauth2.TokenSource
+}
+
+// SearchUsers implements oidc.PrincipalSearcher.
+func (c *Client) SearchUsers(searchTerm string) ([]v3.Principal, error) {
+	var users []user
+	searchURL := fmt.Sprintf(`%s/api/v1/users?q=%s`, orgBaseURL(c.config.Issuer), url.QueryEscape(searchTerm))
+	if err := c.get(searchURL, &users); err != nil {
+		return nil, err
+	}
+	principals := make([]v3.Principal, 0, len(users))
+	for _, u := range users {
+		principals = append(principals, userToPrincipal(u))
+	}
+	return principals, nil
+}
+
+// SearchGroups implements oidc.PrincipalSearcher.
+func (c *Client) SearchGroups(searchTerm string) ([]v3.Principal, error) {
+	var groups []group
+	searchURL := fmt.Sprintf(`%s/api/v1/groups?q=%s`, orgBaseURL(c.config.Issuer), url.QueryEscape(searchTerm))
+	if err := c.get(searchURL, &groups); err != nil {
+		return nil, err
+	}
+	principals := make([]v3.Principal, 0, len(groups))
+	for _, g := range groups {
+		principals = append(principals, groupToPrincipal(g))
+	}
+	return principals, nil
+}
+
+// GetByID implements oidc.PrincipalSearcher.
+func (c *Client) GetByID(principalType, id string) (v3.Principal, error) {
+	if principalType == oidc.GroupType {
+		var g group
+		if err := c.get(fmt.Sprintf("%s/api/v1/groups/%s", orgBaseURL(c.config.Issuer), url.PathEscape(id)), &g); err != nil {
+			return v3.Principal{}, err
+		}
+		return groupToPrincipal(g), nil
+	}
+	var u user
+	if err := c.get(fmt.Sprintf("%s/api/v1/users/%s", orgBaseURL(c.config.Issuer), url.PathEscape(id)), &u); err != nil {
+		return v3.Principal{}, err
+	}
+	return userToPrincipal(u), nil
+}
+
+func (c *Client) get(requestURL string, out interface{}) error {
+	token, err := c.serviceAccountToken()
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodGet, requestURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("[okta oidc]: request to %s failed: %w", requestURL, err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("[okta oidc]: %s returned status %d: %s", requestURL, resp.StatusCode, body)
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		logrus.Errorf("[okta oidc]: failed to unmarshal response from %s: %v", requestURL, err)
+		return err
+	}
+	return nil
+}
+
+// serviceAccountToken obtains (and caches) a client-credentials token from
+// Okta's OAuth authorization server, scoped for the admin management API.
+func (c *Client) serviceAccountToken() (string, error) {
+	if c.serviceTokenSource == nil {
+		c.serviceTokenSource = (&clientcredentials.Config{
+			ClientID:     c.config.ServiceAccountClientID,
+			ClientSecret: c.config.ServiceAccountClientSecret,
+			TokenURL:     tokenURL(c.config.Issuer),
+			Scopes:       []string{"okta.users.read", "okta.groups.read"},
+		}).TokenSource(context.Background())
+	}
+	token, err := c.serviceTokenSource.Token()
+	if err != nil {
+		return "", fmt.Errorf("[okta oidc]: failed to obtain service account token: %w", err)
+	}
+	return token.AccessToken, nil
+}
+
+// orgBaseURL returns the bare Okta org URL (e.g. "https://example.okta.com")
+// derived from config.Issuer. Issuer may be the org authorization server
+// itself or a custom authorization server nested under it (e.g.
+// ".../oauth2/default"), but the org-wide Management API used for
+// user/group search only ever lives at the bare org domain.
+func orgBaseURL(issuer string) string {
+	if idx := strings.Index(issuer, "/oauth2/"); idx != -1 {
+		return issuer[:idx]
+	}
+	return issuer
+}
+
+// tokenURL returns the client-credentials token endpoint for config.Issuer:
+// a custom authorization server's own token endpoint when Issuer points at
+// one, otherwise the org authorization server's.
+func tokenURL(issuer string) string {
+	if strings.Contains(issuer, "/oauth2/") {
+		return issuer + "/v1/token"
+	}
+	return issuer + "/oauth2/v1/token"
+}
+
+func userToPrincipal(u user) v3.Principal {
+	displayName := u.Profile.FirstName + " " + u.Profile.LastName
+	return v3.Principal{
+		ObjectMeta:    metav1.ObjectMeta{Name: oidc.Name + "_" + oidc.UserType + "://" + u.ID},
+		DisplayName:   displayName,
+		LoginName:     u.Profile.Login,
+		PrincipalType: oidc.UserType,
+		Provider:      oidc.Name,
+	}
+}
+
+func groupToPrincipal(g group) v3.Principal {
+	return v3.Principal{
+		ObjectMeta:    metav1.ObjectMeta{Name: oidc.Name + "_" + oidc.GroupType + "://" + g.ID},
+		DisplayName:   g.Profile.Name,
+		PrincipalType: oidc.GroupType,
+		Provider:      oidc.Name,
+	}
+}
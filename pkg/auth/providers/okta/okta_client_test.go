@@ -0,0 +1,17 @@
+package okta
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOrgBaseURL(t *testing.T) {
+	assert.Equal(t, "https://example.okta.com", orgBaseURL("https://example.okta.com"))
+	assert.Equal(t, "https://example.okta.com", orgBaseURL("https://example.okta.com/oauth2/default"))
+}
+
+func TestTokenURL(t *testing.T) {
+	assert.Equal(t, "https://example.okta.com/oauth2/v1/token", tokenURL("https://example.okta.com"))
+	assert.Equal(t, "https://example.okta.com/oauth2/default/v1/token", tokenURL("https://example.okta.com/oauth2/default"))
+}
@@ -0,0 +1,11 @@
+// Package providers has no exported API of its own; importing it registers
+// every oidc.PrincipalSearcher implementation by running each backend
+// package's init(). Without this blank import, AuthBackend: "azuread" or
+// "okta" fails at runtime with "no principal searcher registered for
+// authBackend", since nothing else in the tree references these packages.
+package providers
+
+import (
+	_ "github.com/rancher/rancher/pkg/auth/providers/msgraph"
+	_ "github.com/rancher/rancher/pkg/auth/providers/okta"
+)
@@ -0,0 +1,69 @@
+package scope
+
+import (
+	"testing"
+	"time"
+
+	v3 "github.com/rancher/rancher/pkg/generated/norman/management.cattle.io/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func tokenWithGroups(groups ...v3.Principal) *v3.Token {
+	return &v3.Token{
+		UserPrincipal:   v3.Principal{ObjectMeta: metav1.ObjectMeta{Name: "alice"}},
+		GroupPrincipals: groups,
+	}
+}
+
+func TestMintGroupScopeRequiresGroupPrincipal(t *testing.T) {
+	parent := tokenWithGroups()
+
+	_, err := Mint(parent, "group", TokenScope{Resource: "cluster:c-xxxxx", Role: "view"})
+
+	assert.Error(t, err)
+}
+
+func TestMintGroupScopeSucceeds(t *testing.T) {
+	parent := tokenWithGroups(v3.Principal{ObjectMeta: metav1.ObjectMeta{Name: "oidc_group://cluster:c-xxxxx"}})
+
+	child, err := Mint(parent, "group", TokenScope{Resource: "cluster:c-xxxxx", Role: "view", Expiry: time.Hour})
+
+	require.NoError(t, err)
+	gotScope, ok := Of(child)
+	require.True(t, ok)
+	assert.Equal(t, TokenScope{Resource: "cluster:c-xxxxx", Role: "view"}, gotScope)
+	assert.Empty(t, child.Token)
+}
+
+func TestMintLinkScopeRequiresViewAndExpiry(t *testing.T) {
+	parent := tokenWithGroups()
+
+	_, err := Mint(parent, "link", TokenScope{Resource: "cluster:c-xxxxx", Role: "edit", Expiry: time.Hour})
+	assert.Error(t, err, "link scopes must be read-only")
+
+	_, err = Mint(parent, "link", TokenScope{Resource: "cluster:c-xxxxx", Role: "view"})
+	assert.Error(t, err, "link scopes must carry an expiry")
+
+	child, err := Mint(parent, "link", TokenScope{Resource: "cluster:c-xxxxx", Role: "view", Expiry: time.Hour})
+	require.NoError(t, err)
+	assert.NotEmpty(t, child.Annotations[ExpiresAtAnnotation])
+}
+
+func TestOfReturnsFalseForUnscopedToken(t *testing.T) {
+	_, ok := Of(tokenWithGroups())
+	assert.False(t, ok)
+}
+
+func TestOfRejectsExpiredToken(t *testing.T) {
+	parent := tokenWithGroups(v3.Principal{ObjectMeta: metav1.ObjectMeta{Name: "oidc_group://cluster:c-xxxxx"}})
+
+	child, err := Mint(parent, "group", TokenScope{Resource: "cluster:c-xxxxx", Role: "view", Expiry: time.Hour})
+	require.NoError(t, err)
+
+	child.Annotations[ExpiresAtAnnotation] = time.Now().Add(-time.Minute).Format(time.RFC3339)
+
+	_, ok := Of(child)
+	assert.False(t, ok)
+}